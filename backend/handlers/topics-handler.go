@@ -0,0 +1,274 @@
+// Package handlers wires HTTP endpoints onto the service layer.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Isaacmark1/code-samples/backend/services"
+)
+
+// longPollTimeout bounds how long GET /topics/{topic}/poll blocks
+// waiting for a new message before returning an empty result.
+const longPollTimeout = 30 * time.Second
+
+// TopicsHandler exposes services.TopicBroker over HTTP: SSE, long-poll,
+// and raw JSON stream subscriptions, plus a publish endpoint, in the
+// style of ntfy's topic model.
+type TopicsHandler struct {
+	notifications *services.NotificationService
+	broker        *services.TopicBroker
+}
+
+// NewTopicsHandler creates a handler backed by the given service and the
+// same TopicBroker instance it was constructed with.
+func NewTopicsHandler(notifications *services.NotificationService, broker *services.TopicBroker) *TopicsHandler {
+	return &TopicsHandler{notifications: notifications, broker: broker}
+}
+
+// ServeHTTP dispatches GET/POST /topics/{topic}[/sse|/poll|/stream]
+// requests by inspecting the path, since this handler targets plain
+// net/http without a path-parameter router.
+func (h *TopicsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	topic, action := parseTopicPath(r.URL.Path)
+	if topic == "" || isReservedTopic(topic) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && action == "":
+		h.Publish(w, r, topic)
+	case r.Method == http.MethodGet && action == "sse":
+		h.SSE(w, r, topic)
+	case r.Method == http.MethodGet && action == "poll":
+		h.LongPoll(w, r, topic)
+	case r.Method == http.MethodGet && action == "stream":
+		h.Stream(w, r, topic)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// isReservedTopic reports whether topic is a name reserved for internal
+// use (currently just the firehose pseudo-topic), which must never be
+// reachable through the ordinary per-topic routes - only through
+// Firehose, which is expected to sit behind admin-only middleware.
+func isReservedTopic(topic string) bool {
+	return topic == services.FirehoseTopic
+}
+
+// parseTopicPath splits "/topics/<topic>/<action>" into its parts.
+func parseTopicPath(path string) (topic, action string) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(path, "/topics/"), "/topics")
+	trimmed = strings.Trim(trimmed, "/")
+	if trimmed == "" {
+		return "", ""
+	}
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// Publish handles POST /topics/{topic}: it creates and persists a
+// notification addressed to topic and pushes it to live subscribers.
+// Callers are expected to have already passed auth/scope middleware
+// (e.g. middleware.RequireScope("notifications:write")) before reaching
+// this handler.
+func (h *TopicsHandler) Publish(w http.ResponseWriter, r *http.Request, topic string) {
+	if isReservedTopic(topic) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var in services.CreateNotificationInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	n, err := h.notifications.SendToTopic(r.Context(), topic, in)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+// SSE handles GET /topics/{topic}/sse, streaming messages as
+// Server-Sent Events and replaying anything newer than `?since=` first.
+// The firehose pseudo-topic is rejected here: it's only reachable via
+// Firehose, which is expected to sit behind admin-only middleware.
+func (h *TopicsHandler) SSE(w http.ResponseWriter, r *http.Request, topic string) {
+	if isReservedTopic(topic) {
+		http.NotFound(w, r)
+		return
+	}
+	h.streamSSE(w, r, topic)
+}
+
+// streamSSE is the actual SSE implementation, shared by SSE and
+// Firehose. Unlike SSE, it doesn't reject the firehose pseudo-topic.
+func (h *TopicsHandler) streamSSE(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ch, unsubscribe := h.broker.Subscribe(topic)
+	defer unsubscribe()
+
+	// Subscribe runs before Since so we don't miss a message published in
+	// between; that means a message published in that window can show up
+	// in both Since's result and on ch. Track the highest ID we've
+	// already flushed and skip anything ch delivers that Since already
+	// covered.
+	var lastFlushedID int64
+	for _, msg := range h.broker.Since(topic, sinceParam(r)) {
+		writeSSEMessage(w, msg)
+		lastFlushedID = msg.ID
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if msg.ID <= lastFlushedID {
+				continue
+			}
+			writeSSEMessage(w, msg)
+			lastFlushedID = msg.ID
+			flusher.Flush()
+		}
+	}
+}
+
+// Firehose handles the admin-only GET /firehose endpoint: an SSE stream
+// of every message published on every topic, for observability.
+func (h *TopicsHandler) Firehose(w http.ResponseWriter, r *http.Request) {
+	h.streamSSE(w, r, services.FirehoseTopic)
+}
+
+// LongPoll handles GET /topics/{topic}/poll?since=<id>. It returns
+// immediately with any cached messages newer than since; if there are
+// none, it waits up to longPollTimeout for the next published message
+// before responding with an empty array.
+func (h *TopicsHandler) LongPoll(w http.ResponseWriter, r *http.Request, topic string) {
+	if isReservedTopic(topic) {
+		http.NotFound(w, r)
+		return
+	}
+
+	since := sinceParam(r)
+
+	if cached := h.broker.Since(topic, since); len(cached) > 0 {
+		writeJSON(w, http.StatusOK, cached)
+		return
+	}
+
+	ch, unsubscribe := h.broker.Subscribe(topic)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(r.Context(), longPollTimeout)
+	defer cancel()
+
+	select {
+	case msg := <-ch:
+		writeJSON(w, http.StatusOK, []services.TopicMessage{msg})
+	case <-ctx.Done():
+		writeJSON(w, http.StatusOK, []services.TopicMessage{})
+	}
+}
+
+// Stream handles GET /topics/{topic}/stream: newline-delimited raw JSON,
+// with no SSE framing, for clients that just want to tail a topic.
+func (h *TopicsHandler) Stream(w http.ResponseWriter, r *http.Request, topic string) {
+	if isReservedTopic(topic) {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	ch, unsubscribe := h.broker.Subscribe(topic)
+	defer unsubscribe()
+
+	// See streamSSE: Subscribe runs before Since, so a message published
+	// in between can appear in both; skip anything ch delivers that
+	// Since already flushed.
+	var lastFlushedID int64
+	enc := json.NewEncoder(w)
+	for _, msg := range h.broker.Since(topic, sinceParam(r)) {
+		enc.Encode(msg)
+		lastFlushedID = msg.ID
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if msg.ID <= lastFlushedID {
+				continue
+			}
+			enc.Encode(msg)
+			lastFlushedID = msg.ID
+			flusher.Flush()
+		}
+	}
+}
+
+func sinceParam(r *http.Request) int64 {
+	since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	return since
+}
+
+func writeSSEMessage(w http.ResponseWriter, msg services.TopicMessage) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", msg.ID, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
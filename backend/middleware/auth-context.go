@@ -0,0 +1,65 @@
+package middleware
+
+import "context"
+
+// AuthMethod identifies how a request's caller was authenticated.
+type AuthMethod string
+
+const (
+	AuthMethodBearer   AuthMethod = "bearer"
+	AuthMethodCookie   AuthMethod = "cookie"
+	AuthMethodInternal AuthMethod = "internal"
+	AuthMethodWebhook  AuthMethod = "webhook"
+	AuthMethodOAuth2   AuthMethod = "oauth2"
+)
+
+// UserStatus reflects the account state of an authenticated user.
+type UserStatus string
+
+const (
+	StatusUnconfirmed UserStatus = "unconfirmed"
+	StatusActive      UserStatus = "active"
+	StatusSuspended   UserStatus = "suspended"
+	StatusAdmin       UserStatus = "admin"
+)
+
+// AuthContext carries everything downstream handlers need to know about
+// the authenticated caller: who they are, how they proved it, and what
+// they're allowed to do. AuthMiddleware builds one per request and
+// attaches it to the request context.
+type AuthContext struct {
+	UserID int64
+	Email  string
+	Role   string
+	Status UserStatus
+	Method AuthMethod
+	Grants []string
+}
+
+// HasGrant reports whether the caller's token carries the given scope.
+func (a *AuthContext) HasGrant(scope string) bool {
+	if a == nil {
+		return false
+	}
+	for _, g := range a.Grants {
+		if g == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey string
+
+const authContextKey contextKey = "authContext"
+
+// WithAuthContext attaches an AuthContext to ctx.
+func WithAuthContext(ctx context.Context, ac *AuthContext) context.Context {
+	return context.WithValue(ctx, authContextKey, ac)
+}
+
+// AuthContextFromContext retrieves the AuthContext set by AuthMiddleware.
+func AuthContextFromContext(ctx context.Context) (*AuthContext, bool) {
+	ac, ok := ctx.Value(authContextKey).(*AuthContext)
+	return ac, ok
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
 	"strings"
 )
@@ -14,130 +14,192 @@ type TokenMaker interface {
 	VerifyToken(token string) (*TokenPayload, error)
 }
 
-// TokenPayload represents authenticated user data
+// TokenPayload represents authenticated user data. Status and Grants are
+// optional: for TokenMakers that don't populate them yet, a missing
+// Status defaults to StatusActive and missing Grants fall back to
+// grantsForRole, so existing tokens keep working.
 type TokenPayload struct {
-	UserID int64  `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID int64      `json:"user_id"`
+	Email  string     `json:"email"`
+	Role   string     `json:"role"`
+	Status UserStatus `json:"status,omitempty"`
+	Grants []string   `json:"grants,omitempty"`
 }
 
-// AuthMiddleware provides JWT authentication with fallback to cookie-based auth
+// AuthMiddleware authenticates a request via an internal service token,
+// a JWT/opaque Authorization: Bearer token, or a session cookie (in that
+// order of preference), and attaches the resulting AuthContext to the
+// request so downstream middleware like RequireScope can authorize it.
 func AuthMiddleware(tokenMaker TokenMaker, db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var userID int64
-			var payloadValid bool
-			
-			// Try Authorization: Bearer <token> header first
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" {
-				parts := strings.Fields(authHeader)
-				if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-					tokenStr := parts[1]
-					payload, err := tokenMaker.VerifyToken(tokenStr)
-					if err != nil {
-						log.Printf("❌ [Auth] Invalid token: %v", err)
-						w.Header().Set("Content-Type", "application/json")
-						w.WriteHeader(http.StatusUnauthorized)
-						json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired token"})
-						return
-					}
-					userID = payload.UserID
-					payloadValid = true
-				}
+			payload, method, err := authenticateRequest(r, tokenMaker)
+			if err != nil {
+				loggerFromContext(r.Context()).Warn("authentication failed", "error", err.Error())
+				writeAuthError(w, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
+				return
+			}
+			if payload == nil {
+				loggerFromContext(r.Context()).Warn("missing authentication", "method", r.Method, "path", r.URL.Path)
+				writeAuthError(w, http.StatusUnauthorized, "authentication_required", "Authentication required")
+				return
 			}
 
-			// Fallback to cookie-based authentication
-			if !payloadValid {
-				cookie, err := r.Cookie("auth_token")
-				if err != nil || cookie.Value == "" {
-					log.Printf("🚫 [Auth] Missing authentication for %s %s", r.Method, r.URL.Path)
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusUnauthorized)
-					json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
-					return
-				}
-
-				payload, err := tokenMaker.VerifyToken(cookie.Value)
-				if err != nil {
-					log.Printf("❌ [Auth] Invalid token: %v", err)
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusUnauthorized)
-					json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired token"})
-					return
-				}
-				userID = payload.UserID
+			ac := &AuthContext{
+				UserID: payload.UserID,
+				Email:  payload.Email,
+				Role:   payload.Role,
+				Status: payload.Status,
+				Method: method,
+				Grants: payload.Grants,
+			}
+			if ac.Status == "" {
+				ac.Status = StatusActive
+			}
+			if len(ac.Grants) == 0 {
+				ac.Grants = grantsForRole(payload.Role)
 			}
 
 			// Update last_seen asynchronously to avoid blocking request
-			if db != nil && userID > 0 {
-				go func() {
+			if db != nil && ac.UserID > 0 {
+				requestID, _ := RequestIDFromContext(r.Context())
+				go func(userID int64, requestID string) {
 					_, err := db.Exec("UPDATE users SET last_seen = NOW() WHERE id = ?", userID)
 					if err != nil {
-						log.Printf("Warning: Failed to update last_seen for user %d: %v", userID, err)
+						logger.Warn("failed to update last_seen", "user_id", userID, "request_id", requestID, "error", err.Error())
 					}
-				}()
+				}(ac.UserID, requestID)
 			}
 
-			// Add user context to request
-			ctx := context.WithValue(r.Context(), "userID", userID)
+			// Add auth context to request. "userID" is kept alongside it
+			// for handlers that haven't migrated to AuthContextFromContext yet.
+			ctx := WithAuthContext(r.Context(), ac)
+			ctx = context.WithValue(ctx, "userID", ac.UserID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// AdminMiddleware ensures authenticated user has admin privileges
-func AdminMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+// authenticateRequest tries each supported auth method in turn and
+// returns the verified payload along with the method that produced it.
+// A nil payload and nil error means no credential was presented at all.
+func authenticateRequest(r *http.Request, tokenMaker TokenMaker) (*TokenPayload, AuthMethod, error) {
+	if serviceToken := r.Header.Get("X-Service-Token"); serviceToken != "" {
+		payload, err := tokenMaker.VerifyToken(serviceToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid service token: %w", err)
+		}
+		return payload, AuthMethodInternal, nil
+	}
+
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		parts := strings.Fields(authHeader)
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			return nil, "", fmt.Errorf("malformed Authorization header")
+		}
+		payload, err := tokenMaker.VerifyToken(parts[1])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid token: %w", err)
+		}
+		return payload, bearerTokenMethod(parts[1]), nil
+	}
+
+	cookie, err := r.Cookie("auth_token")
+	if err != nil || cookie.Value == "" {
+		return nil, "", nil
+	}
+	payload, err := tokenMaker.VerifyToken(cookie.Value)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid token: %w", err)
+	}
+	return payload, AuthMethodCookie, nil
+}
+
+// bearerTokenMethod distinguishes legacy 32-hex opaque tokens from
+// base64url-encoded OAuth2 access tokens so AuthContext.Method reflects
+// which credential format was actually presented.
+func bearerTokenMethod(token string) AuthMethod {
+	if OAuth2TokenMatcher(token) {
+		return AuthMethodOAuth2
+	}
+	return AuthMethodBearer
+}
+
+// grantsForRole derives a default scope set from a legacy role string,
+// for TokenMakers that haven't been updated to issue Grants directly.
+func grantsForRole(role string) []string {
+	if role == "admin" {
+		return []string{"admin", "notifications:read", "notifications:write"}
+	}
+	return []string{"notifications:read"}
+}
+
+// writeAuthError writes a JSON error body carrying a machine-readable
+// code alongside the human-readable message.
+func writeAuthError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": code})
+}
+
+// RequireScope returns middleware that rejects requests whose AuthContext
+// lacks the given scope with a 403 and a machine-readable "missing_scope"
+// error code. It must run after AuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get userID from context (set by AuthMiddleware)
-			userID, ok := r.Context().Value("userID").(int64)
-			if !ok || userID == 0 {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+			ac, ok := AuthContextFromContext(r.Context())
+			if !ok {
+				writeAuthError(w, http.StatusUnauthorized, "authentication_required", "Authentication required")
 				return
 			}
-
-			// Check if user has admin role
-			var role string
-			var email string
-			err := db.QueryRow("SELECT role, email FROM users WHERE id = ?", userID).Scan(&role, &email)
-			if err != nil {
-				log.Printf("❌ [Admin] Failed to get user role and email: %v", err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to verify admin privileges"})
+			if !ac.HasGrant(scope) {
+				loggerFromContext(r.Context()).Warn("missing scope", "user_id", ac.UserID, "scope", scope, "method", r.Method, "path", r.URL.Path)
+				writeAuthError(w, http.StatusForbidden, "missing_scope", fmt.Sprintf("scope %q required", scope))
 				return
 			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			// Check if user has admin role
-			isAdmin := role == "admin"
-
-			// If not admin by role, check if email is in admin list
-			if !isAdmin {
-				// This would typically come from environment variables
-				adminEmails := []string{"admin@example.com"} // Sanitized
-				userEmail := strings.ToLower(strings.TrimSpace(email))
-				for _, adminEmail := range adminEmails {
-					if strings.ToLower(strings.TrimSpace(adminEmail)) == userEmail {
-						isAdmin = true
-						break
-					}
+// AdminMiddleware ensures the authenticated caller holds the "admin"
+// scope. It's now a thin wrapper over RequireScope; the only thing it
+// adds is a fallback for tokens issued before Grants existed, which
+// consults the users table the same way the old role/email check did.
+func AdminMiddleware(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		requireAdmin := RequireScope("admin")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ac, ok := AuthContextFromContext(r.Context()); ok && !ac.HasGrant("admin") && db != nil {
+				if isAdmin := legacyIsAdmin(r.Context(), db, ac.UserID); isAdmin {
+					ac.Grants = append(ac.Grants, "admin")
 				}
 			}
-
-			if !isAdmin {
-				log.Printf("🚫 [Admin] User %d (%s) attempted admin access without privileges", userID, email)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusForbidden)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Admin privileges required"})
-				return
-			}
-
-			// User is admin, proceed
-			log.Printf("✅ [Admin] User %d (%s) accessing %s %s", userID, email, r.Method, r.URL.Path)
-			next.ServeHTTP(w, r)
+			requireAdmin(next).ServeHTTP(w, r)
 		})
 	}
 }
+
+// legacyIsAdmin reproduces the pre-scopes admin check (role column, with
+// a fallback to a hardcoded admin email allowlist) for TokenMakers that
+// don't yet issue admin as a grant.
+func legacyIsAdmin(ctx context.Context, db *sql.DB, userID int64) bool {
+	var role, email string
+	if err := db.QueryRow("SELECT role, email FROM users WHERE id = ?", userID).Scan(&role, &email); err != nil {
+		loggerFromContext(ctx).Error("failed to get user role and email", "user_id", userID, "error", err.Error())
+		return false
+	}
+	if role == "admin" {
+		return true
+	}
+	// This would typically come from environment variables
+	adminEmails := []string{"admin@example.com"} // Sanitized
+	userEmail := strings.ToLower(strings.TrimSpace(email))
+	for _, adminEmail := range adminEmails {
+		if strings.ToLower(strings.TrimSpace(adminEmail)) == userEmail {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 // CORSMiddleware handles Cross-Origin Resource Sharing configuration
@@ -47,54 +48,77 @@ func SecurityHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Prevent clickjacking
 		w.Header().Set("X-Frame-Options", "DENY")
-		
+
 		// Prevent MIME type sniffing
 		w.Header().Set("X-Content-Type-Options", "nosniff")
-		
+
 		// Enable XSS protection
 		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		
+
 		// Force HTTPS (in production)
 		if os.Getenv("ENVIRONMENT") == "production" {
 			w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
 		}
-		
+
 		// Content Security Policy
 		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'")
-		
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-// LoggingMiddleware logs all requests with response times
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		
-		// Create a response writer to capture status code
-		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		
-		next.ServeHTTP(lrw, r)
-		
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %d %v %s",
-			r.Method,
-			r.URL.Path,
-			lrw.statusCode,
-			duration,
-			r.UserAgent(),
-		)
-	})
+// LoggingMiddleware emits one structured log line per request: method,
+// path, status, duration, response size, client IP, and - once
+// AuthMiddleware or WebhookAuthMiddleware has run - the caller's user ID
+// and auth method. trustedProxies controls which immediate peers are
+// allowed to set X-Forwarded-For/CF-Connecting-IP.
+func LoggingMiddleware(trustedProxies []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Create a response writer to capture status code and size
+			lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(lrw, r)
+
+			var userID int64
+			var authMethod AuthMethod
+			if ac, ok := AuthContextFromContext(r.Context()); ok {
+				userID = ac.UserID
+				authMethod = ac.Method
+			}
+
+			loggerFromContext(r.Context()).Info("request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", lrw.statusCode,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"bytes", lrw.bytesWritten,
+				"client_ip", resolveClientIP(r, trustedProxies),
+				"user_id", userID,
+				"auth_method", string(authMethod),
+				"user_agent", r.UserAgent(),
+			)
+		})
+	}
 }
 
-// loggingResponseWriter wraps http.ResponseWriter to capture status code
+// loggingResponseWriter wraps http.ResponseWriter to capture the status
+// code and the number of response bytes written.
 type loggingResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytesWritten += n
+	return n, err
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// logger is the package-wide structured logger: JSON output, so fields
+// like request_id can be correlated by whatever ingests these logs.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// loggerFromContext returns logger with request_id already attached, if
+// RequestIDMiddleware ran, so every log line emitted while handling one
+// request - auth failures, DB errors, delivery attempts - can be
+// correlated without threading the ID through every call site.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		return logger.With("request_id", id)
+	}
+	return logger
+}
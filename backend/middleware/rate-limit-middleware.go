@@ -0,0 +1,418 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// VisitorLimits bounds what one visitor may do per route: requests per
+// second, bytes per second, and a hard daily message cap, each with a
+// burst allowance.
+type VisitorLimits struct {
+	RequestsPerSecond float64
+	RequestBurst      float64
+	BytesPerSecond    float64
+	ByteBurst         float64
+	MessagesPerDay    int
+}
+
+// DefaultVisitorLimits is what a visitor gets unless a per-route
+// override or a DB-backed tier applies.
+func DefaultVisitorLimits() VisitorLimits {
+	return VisitorLimits{
+		RequestsPerSecond: 1,
+		RequestBurst:      10,
+		BytesPerSecond:    1 << 20,
+		ByteBurst:         1 << 22,
+		MessagesPerDay:    200,
+	}
+}
+
+// TierLookup resolves a visitor's limits from their role/plan (e.g. by
+// querying the users table). It returns ok=false to fall back to the
+// route's configured limits.
+type TierLookup func(db *sql.DB, userID int64) (limits VisitorLimits, ok bool)
+
+// RateLimitConfig controls RateLimitMiddleware.
+type RateLimitConfig struct {
+	Default VisitorLimits
+	// RouteOverrides keys are "METHOD path", e.g. "POST /notifications",
+	// letting a route tighten (or loosen) Default.
+	RouteOverrides map[string]VisitorLimits
+	// Tiers, if set, is consulted for authenticated visitors and takes
+	// precedence over Default/RouteOverrides when it returns ok=true.
+	Tiers TierLookup
+	// IdleTimeout is how long a visitor may go unseen before the janitor
+	// evicts it.
+	IdleTimeout time.Duration
+	// TrustedProxies lists the proxy addresses allowed to set
+	// X-Forwarded-For/CF-Connecting-IP.
+	TrustedProxies []string
+}
+
+// DefaultRateLimitConfig returns DefaultVisitorLimits with a 10 minute
+// idle eviction window and no per-route or per-tier overrides.
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{Default: DefaultVisitorLimits(), IdleTimeout: 10 * time.Minute}
+}
+
+var rateLimitDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ratelimit_dropped_total",
+		Help: "Requests rejected by RateLimitMiddleware, by reason.",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitDropped)
+}
+
+// RateLimitMiddleware tracks a "visitor" per IP (or per authenticated
+// user ID, once AuthMiddleware has run) and enforces token-bucket limits
+// on request rate, bandwidth, and daily message volume, similar to
+// ntfy's visitor model. Rejected requests get a 429 with Retry-After and
+// X-RateLimit-* headers; a background janitor evicts idle visitors so
+// the visitor map doesn't grow without bound.
+func RateLimitMiddleware(db *sql.DB, cfg RateLimitConfig) func(http.Handler) http.Handler {
+	if cfg.Default.RequestsPerSecond == 0 {
+		cfg.Default = DefaultVisitorLimits()
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 10 * time.Minute
+	}
+
+	store := newVisitorStore(cfg, db)
+	go store.janitor(nil)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limits := store.limitsFor(r)
+			key := visitorKey(r, cfg.TrustedProxies) + "|" + routeKey(r)
+			v := store.get(key, limits)
+
+			bodySize := float64(r.ContentLength)
+			if bodySize < 0 {
+				bodySize = 0
+			}
+
+			if ok, retry := v.requests.take(1); !ok {
+				rateLimitDropped.WithLabelValues("rate").Inc()
+				writeRateLimited(w, retry, v, limits)
+				return
+			}
+			if ok, retry := v.bandwidth.take(bodySize); !ok {
+				rateLimitDropped.WithLabelValues("bandwidth").Inc()
+				writeRateLimited(w, retry, v, limits)
+				return
+			}
+			if ok, resetAt := v.takeDaily(); !ok {
+				rateLimitDropped.WithLabelValues("daily_volume").Inc()
+				writeRateLimited(w, time.Until(resetAt), v, limits)
+				return
+			}
+
+			setRateLimitHeaders(w, v, limits)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration, v *visitor, limits VisitorLimits) {
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	setRateLimitHeaders(w, v, limits)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded", "code": "rate_limited"})
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, v *visitor, limits VisitorLimits) {
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatFloat(limits.RequestsPerSecond, 'f', -1, 64))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(v.requests.remaining()))
+}
+
+// routeKey identifies a visitor's rate-limit bucket by route, not by
+// literal path: parameterized routes (currently just the /topics/{topic}
+// family) collapse their variable segment so every topic shares one
+// bucket per visitor instead of getting a fresh, untouched token bucket
+// per distinct topic name. This also makes RouteOverrides lookups work
+// for those routes, since an override keyed on a pattern like
+// "POST /topics/{topic}" can never match a literal path.
+func routeKey(r *http.Request) string {
+	return r.Method + " " + normalizeRoutePath(r.URL.Path)
+}
+
+// normalizeRoutePath collapses the variable topic segment of
+// "/topics/{topic}[/action]", mirroring how handlers.parseTopicPath
+// classifies the same path, so every topic name maps to the same
+// pattern here.
+func normalizeRoutePath(path string) string {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(path, "/topics/"), "/topics")
+	if trimmed == path {
+		return path
+	}
+	trimmed = strings.Trim(trimmed, "/")
+	if trimmed == "" {
+		return "/topics"
+	}
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) == 1 {
+		return "/topics/{topic}"
+	}
+	return "/topics/{topic}/" + parts[1]
+}
+
+// visitorKey identifies a visitor by authenticated user ID when
+// available (set by AuthMiddleware), falling back to client IP.
+func visitorKey(r *http.Request, trustedProxies []string) string {
+	if ac, ok := AuthContextFromContext(r.Context()); ok && ac.UserID > 0 {
+		return fmt.Sprintf("user:%d", ac.UserID)
+	}
+	return "ip:" + resolveClientIP(r, trustedProxies)
+}
+
+// resolveClientIP returns the real client IP, honoring
+// X-Forwarded-For/CF-Connecting-IP only when the immediate peer is a
+// configured trusted proxy.
+func resolveClientIP(r *http.Request, trustedProxies []string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if isTrustedProxy(host, trustedProxies) {
+		if ip := r.Header.Get("CF-Connecting-IP"); ip != "" {
+			return ip
+		}
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+
+	return host
+}
+
+func isTrustedProxy(host string, trusted []string) bool {
+	for _, t := range trusted {
+		if t == host {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenBucket is a classic token-bucket limiter: tokens refill
+// continuously at `rate` per second up to `capacity`, and each take
+// consumes tokens or reports how long to wait until enough accumulate.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: time.Now()}
+}
+
+// take attempts to remove n tokens, refilling first based on elapsed
+// time. It reports whether the request may proceed and, if not, how
+// long the caller should wait before retrying.
+func (b *tokenBucket) take(n float64) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens >= n {
+		b.tokens -= n
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+func (b *tokenBucket) remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int(b.tokens)
+}
+
+// setLimits updates the bucket's rate and capacity in place, clamping
+// any already-accumulated tokens down to the new capacity.
+func (b *tokenBucket) setLimits(rate, capacity float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.capacity = capacity
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+}
+
+// visitor holds the token buckets and daily counter for one rate-limited
+// caller on one route.
+type visitor struct {
+	limits    VisitorLimits
+	requests  *tokenBucket
+	bandwidth *tokenBucket
+
+	mu       sync.Mutex
+	dayCount int
+	dayReset time.Time
+	lastSeen time.Time
+}
+
+func newVisitor(limits VisitorLimits) *visitor {
+	now := time.Now()
+	return &visitor{
+		limits:    limits,
+		requests:  newTokenBucket(limits.RequestsPerSecond, limits.RequestBurst),
+		bandwidth: newTokenBucket(limits.BytesPerSecond, limits.ByteBurst),
+		dayReset:  now.Add(24 * time.Hour),
+		lastSeen:  now,
+	}
+}
+
+func (v *visitor) touch() {
+	v.mu.Lock()
+	v.lastSeen = time.Now()
+	v.mu.Unlock()
+}
+
+// updateLimits swaps in newly resolved limits if they differ from what
+// the visitor currently has, adjusting the token buckets' rate/capacity
+// in place. Without this, a tier change (e.g. a plan upgrade) for an
+// already-tracked visitor wouldn't take effect until the janitor evicts
+// them after IdleTimeout.
+func (v *visitor) updateLimits(limits VisitorLimits) {
+	v.mu.Lock()
+	changed := limits != v.limits
+	if changed {
+		v.limits = limits
+	}
+	v.mu.Unlock()
+
+	if changed {
+		v.requests.setLimits(limits.RequestsPerSecond, limits.RequestBurst)
+		v.bandwidth.setLimits(limits.BytesPerSecond, limits.ByteBurst)
+	}
+}
+
+func (v *visitor) idleSince() time.Duration {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return time.Since(v.lastSeen)
+}
+
+// takeDaily consumes one daily message allotment, rolling the counter
+// over if the day has elapsed. It reports whether the request may
+// proceed and, if not, when the counter resets.
+func (v *visitor) takeDaily() (bool, time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if now.After(v.dayReset) {
+		v.dayCount = 0
+		v.dayReset = now.Add(24 * time.Hour)
+	}
+	if v.dayCount >= v.limits.MessagesPerDay {
+		return false, v.dayReset
+	}
+	v.dayCount++
+	return true, v.dayReset
+}
+
+// visitorStore tracks every live visitor and evicts idle ones.
+type visitorStore struct {
+	mu       sync.Mutex
+	visitors map[string]*visitor
+	cfg      RateLimitConfig
+	db       *sql.DB
+}
+
+func newVisitorStore(cfg RateLimitConfig, db *sql.DB) *visitorStore {
+	return &visitorStore{visitors: make(map[string]*visitor), cfg: cfg, db: db}
+}
+
+// get returns the visitor for key, creating one with limits if it
+// doesn't exist yet. For an existing visitor, limits is re-applied on
+// every call (see visitor.updateLimits) so a tier/route limit change
+// takes effect on this visitor's very next request.
+func (s *visitorStore) get(key string, limits VisitorLimits) *visitor {
+	s.mu.Lock()
+	v, ok := s.visitors[key]
+	if !ok {
+		v = newVisitor(limits)
+		s.visitors[key] = v
+	}
+	s.mu.Unlock()
+
+	v.touch()
+	if ok {
+		v.updateLimits(limits)
+	}
+	return v
+}
+
+// limitsFor resolves the limits that apply to r: a per-tier limit if
+// configured and the caller is authenticated, otherwise the route's
+// override or the config default.
+func (s *visitorStore) limitsFor(r *http.Request) VisitorLimits {
+	limits := s.cfg.Default
+	if override, ok := s.cfg.RouteOverrides[routeKey(r)]; ok {
+		limits = override
+	}
+	if s.cfg.Tiers != nil {
+		if ac, ok := AuthContextFromContext(r.Context()); ok && ac.UserID > 0 {
+			if tier, ok := s.cfg.Tiers(s.db, ac.UserID); ok {
+				limits = tier
+			}
+		}
+	}
+	return limits
+}
+
+// janitor evicts visitors idle longer than cfg.IdleTimeout, bounding the
+// store's memory use. It runs until stop is closed, or forever if stop
+// is nil.
+func (s *visitorStore) janitor(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.cfg.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			for key, v := range s.visitors {
+				if v.idleSince() > s.cfg.IdleTimeout {
+					delete(s.visitors, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID RequestIDMiddleware
+// attached to ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// RequestIDMiddleware propagates an inbound X-Request-ID header (or the
+// trace ID encoded in a W3C traceparent header), or generates a new ID
+// if neither is present. It echoes the ID back on the response and
+// injects it into the request context so downstream logging can
+// correlate every line - auth, DB, notification delivery - produced
+// while handling this request.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestIDFromHeaders(r)
+		if id == "" {
+			id = generateRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestIDFromHeaders(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+
+	// traceparent format: version-traceid-parentid-flags
+	if tp := r.Header.Get("traceparent"); tp != "" {
+		parts := strings.Split(tp, "-")
+		if len(parts) == 4 && len(parts[1]) == 32 {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
+
+func generateRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
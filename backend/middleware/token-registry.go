@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	legacyTokenPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+	oauth2TokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+)
+
+// TokenVerifier verifies one token format. Matches reports whether a
+// given token even looks like something this verifier can handle, so a
+// TokenRegistry can dispatch without trying every verifier in turn.
+type TokenVerifier interface {
+	TokenMaker
+	Matches(token string) bool
+}
+
+// TokenRegistry dispatches token verification to whichever registered
+// TokenVerifier recognizes the token's format. It implements TokenMaker
+// itself, so it can be handed to AuthMiddleware in place of a single
+// verifier when a service needs to accept more than one credential type
+// (e.g. legacy opaque tokens alongside OAuth2 access tokens).
+type TokenRegistry struct {
+	verifiers []TokenVerifier
+}
+
+// NewTokenRegistry creates an empty registry. Verifiers are tried in the
+// order they're registered.
+func NewTokenRegistry() *TokenRegistry {
+	return &TokenRegistry{}
+}
+
+// Register adds a verifier to the registry.
+func (r *TokenRegistry) Register(v TokenVerifier) {
+	r.verifiers = append(r.verifiers, v)
+}
+
+// VerifyToken implements TokenMaker by finding the first registered
+// verifier whose Matches reports true and delegating to it.
+func (r *TokenRegistry) VerifyToken(token string) (*TokenPayload, error) {
+	for _, v := range r.verifiers {
+		if v.Matches(token) {
+			return v.VerifyToken(token)
+		}
+	}
+	return nil, fmt.Errorf("no registered verifier recognizes this token format")
+}
+
+// LegacyTokenMatcher recognizes 32 character hex opaque tokens.
+func LegacyTokenMatcher(token string) bool {
+	return legacyTokenPattern.MatchString(token)
+}
+
+// OAuth2TokenMatcher recognizes base64url-encoded opaque OAuth2 access
+// tokens: 20 or more characters from the base64url alphabet (which
+// includes `-`/`_`), excluding anything that is a legacy token.
+func OAuth2TokenMatcher(token string) bool {
+	return !legacyTokenPattern.MatchString(token) && oauth2TokenPattern.MatchString(token)
+}
@@ -0,0 +1,18 @@
+package services
+
+import "context"
+
+// DeliveryAttempt records one channel's attempt to deliver one
+// notification, so admins can see why a send failed and retry it.
+type DeliveryAttempt struct {
+	NotificationID int64  `json:"notification_id"`
+	Channel        string `json:"channel"`
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+	AttemptedAt    string `json:"attempted_at"`
+}
+
+// DeliveryAuditRepository persists DeliveryAttempts.
+type DeliveryAuditRepository interface {
+	Record(ctx context.Context, attempt DeliveryAttempt) error
+}
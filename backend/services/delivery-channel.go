@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeliveryOptions carries per-attempt tuning that a DeliveryChannel may
+// use, such as how long it's allowed to take before being cancelled.
+type DeliveryOptions struct {
+	Timeout time.Duration
+}
+
+// DeliveryChannel is one way a Notification can reach a user: WebSocket
+// push, SMTP email, FCM/APNs mobile push, a generic HTTP webhook
+// (OpsGenie, PagerDuty, ...), etc. Implementations should treat ctx's
+// deadline as authoritative and return promptly once it expires.
+type DeliveryChannel interface {
+	Name() string
+	Deliver(ctx context.Context, n *Notification, opts DeliveryOptions) error
+}
+
+// ChannelRegistry holds the DeliveryChannel implementations a
+// NotificationService can fan a notification out to, keyed by name so
+// NotificationPreferences can reference them.
+type ChannelRegistry struct {
+	mu       sync.RWMutex
+	channels map[string]DeliveryChannel
+}
+
+// NewChannelRegistry creates an empty registry.
+func NewChannelRegistry() *ChannelRegistry {
+	return &ChannelRegistry{channels: make(map[string]DeliveryChannel)}
+}
+
+// Register adds a channel, replacing any previously registered channel
+// with the same name.
+func (r *ChannelRegistry) Register(ch DeliveryChannel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[ch.Name()] = ch
+}
+
+// Get looks up a channel by name.
+func (r *ChannelRegistry) Get(name string) (DeliveryChannel, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ch, ok := r.channels[name]
+	return ch, ok
+}
+
+// websocketChannel adapts the existing WebSocketHub into a DeliveryChannel
+// so real-time push participates in the same fan-out, retry, and audit
+// path as every other channel instead of being special-cased.
+type websocketChannel struct {
+	hub WebSocketHub
+}
+
+func (c *websocketChannel) Name() string { return "websocket" }
+
+func (c *websocketChannel) Deliver(ctx context.Context, n *Notification, opts DeliveryOptions) error {
+	if err := c.hub.SendToUser(n.UserID, n); err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+	return nil
+}
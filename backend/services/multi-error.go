@@ -0,0 +1,35 @@
+package services
+
+import "strings"
+
+// MultiError collects the errors from several independent operations
+// (e.g. one per delivery channel) so callers can inspect every failure
+// instead of just the first.
+type MultiError struct {
+	Errors []error
+}
+
+// Add appends err if it's non-nil.
+func (m *MultiError) Add(err error) {
+	if err != nil {
+		m.Errors = append(m.Errors, err)
+	}
+}
+
+// ErrorOrNil returns m if it holds at least one error, otherwise nil -
+// useful for returning `*MultiError` from a function's `error` field
+// without a typed-nil trap.
+func (m *MultiError) ErrorOrNil() error {
+	if m == nil || len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	parts := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		parts[i] = err.Error()
+	}
+	return strings.Join(parts, "; ")
+}
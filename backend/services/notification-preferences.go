@@ -0,0 +1,25 @@
+package services
+
+import "context"
+
+// NotificationPreferences records which delivery channels a user has
+// opted into, per notification type. The special type "*" acts as the
+// fallback for types the user hasn't configured explicitly.
+type NotificationPreferences struct {
+	UserID   int64
+	Channels map[string][]string
+}
+
+// ChannelsFor returns the channel names a user wants notifications of
+// the given type delivered through, falling back to their "*" default.
+func (p NotificationPreferences) ChannelsFor(notificationType string) []string {
+	if chans, ok := p.Channels[notificationType]; ok {
+		return chans
+	}
+	return p.Channels["*"]
+}
+
+// PreferencesRepository loads a user's NotificationPreferences.
+type PreferencesRepository interface {
+	Get(ctx context.Context, userID int64) (NotificationPreferences, error)
+}
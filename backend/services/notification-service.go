@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -19,6 +20,7 @@ type NotificationRepository interface {
 type WebSocketHub interface {
 	SendToUser(userID int64, data interface{}) error
 	Broadcast(data interface{}) error
+	SendToTopic(topic string, data interface{}) error
 }
 
 // Notification represents a user notification
@@ -28,21 +30,57 @@ type Notification struct {
 	Title     string `json:"title"`
 	Message   string `json:"message"`
 	Type      string `json:"type"` // info|success|warning|error
+	Topic     string `json:"topic,omitempty"`
 	IsRead    bool   `json:"is_read"`
 	CreatedAt string `json:"created_at"`
 }
 
+// defaultMaxConcurrentDeliveries bounds how many channel deliveries run
+// at once across the whole service. The bound is shared by every
+// channel: while all slots are held by slow deliveries (e.g. to an SMTP
+// server), deliveries on other channels, WebSocket included, wait for a
+// free slot.
+const defaultMaxConcurrentDeliveries = 8
+
+// defaultChannelTimeout bounds a single channel's delivery attempt
+// (including retries) when the caller doesn't specify one.
+const defaultChannelTimeout = 10 * time.Second
+
 // NotificationService handles notification business logic
 type NotificationService struct {
-	repo NotificationRepository
-	hub  WebSocketHub
+	repo        NotificationRepository
+	hub         WebSocketHub
+	channels    *ChannelRegistry
+	prefs       PreferencesRepository
+	audit       DeliveryAuditRepository
+	retryPolicy RetryPolicy
+	sem         chan struct{}
+	topics      *TopicBroker
 }
 
-// NewNotificationService creates a new notification service
-func NewNotificationService(repo NotificationRepository, hub WebSocketHub) *NotificationService {
+// NewNotificationService creates a new notification service. hub, if
+// non-nil, is registered into channels as the "websocket" channel so it
+// participates in the same fan-out as every other DeliveryChannel; prefs
+// and audit may be nil, in which case delivery falls back to "websocket"
+// only and attempts aren't recorded. topics may be nil, in which case
+// Topic-targeted notifications are persisted but never published to
+// subscribers.
+func NewNotificationService(repo NotificationRepository, hub WebSocketHub, channels *ChannelRegistry, prefs PreferencesRepository, audit DeliveryAuditRepository, topics *TopicBroker) *NotificationService {
+	if channels == nil {
+		channels = NewChannelRegistry()
+	}
+	if hub != nil {
+		channels.Register(&websocketChannel{hub: hub})
+	}
 	return &NotificationService{
-		repo: repo,
-		hub:  hub,
+		repo:        repo,
+		hub:         hub,
+		channels:    channels,
+		prefs:       prefs,
+		audit:       audit,
+		retryPolicy: DefaultRetryPolicy(),
+		sem:         make(chan struct{}, defaultMaxConcurrentDeliveries),
+		topics:      topics,
 	}
 }
 
@@ -52,13 +90,21 @@ type CreateNotificationInput struct {
 	Title   string `json:"title"`
 	Message string `json:"message"`
 	Type    string `json:"type"` // info|success|warning|error
+	Topic   string `json:"topic,omitempty"`
 }
 
-// Validate ensures notification data is valid
+// Validate ensures notification data is valid for a user-targeted
+// notification.
 func (in CreateNotificationInput) Validate() error {
 	if in.UserID <= 0 {
 		return fmt.Errorf("user_id is required and must be positive")
 	}
+	return in.validateContent()
+}
+
+// validateContent checks the fields shared by every notification,
+// regardless of whether it's targeted at a user or a topic.
+func (in CreateNotificationInput) validateContent() error {
 	if in.Title == "" {
 		return fmt.Errorf("title is required")
 	}
@@ -101,6 +147,7 @@ func (s *NotificationService) CreateAndPush(ctx context.Context, in CreateNotifi
 		Title:     in.Title,
 		Message:   in.Message,
 		Type:      in.Type,
+		Topic:     in.Topic,
 		IsRead:    false,
 		CreatedAt: time.Now().Format(time.RFC3339),
 	}
@@ -110,17 +157,145 @@ func (s *NotificationService) CreateAndPush(ctx context.Context, in CreateNotifi
 		return fmt.Errorf("failed to save notification: %w", err)
 	}
 
-	// Send real-time notification via WebSocket
-	if s.hub != nil {
-		if err := s.hub.SendToUser(in.UserID, n); err != nil {
-			// Log error but don't fail the operation
-			log.Printf("Warning: Failed to send real-time notification to user %d: %v", in.UserID, err)
-		}
+	// Publish to topic subscribers (SSE/long-poll/stream), in addition
+	// to the per-user channel fan-out below.
+	if n.Topic != "" && s.topics != nil {
+		s.topics.Publish(n.Topic, n)
+	}
+
+	// Fan out to every channel the user has opted into for this
+	// notification type. The notification is already persisted, so
+	// delivery failures are logged rather than failing the operation -
+	// the audit trail is what admins use to retry.
+	if merr := s.deliver(ctx, n); merr.ErrorOrNil() != nil {
+		log.Printf("Warning: delivery failures for notification %d: %v", n.ID, merr)
 	}
 
 	return nil
 }
 
+// SendToTopic creates and persists a notification addressed to a topic
+// rather than a specific user, and publishes it to that topic's
+// subscribers. Unlike CreateAndPush, UserID is optional: topic messages
+// are often published by a system or admin on behalf of no one user in
+// particular.
+func (s *NotificationService) SendToTopic(ctx context.Context, topic string, in CreateNotificationInput) (*Notification, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	if err := in.validateContent(); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	n := &Notification{
+		UserID:    in.UserID,
+		Title:     in.Title,
+		Message:   in.Message,
+		Type:      in.Type,
+		Topic:     topic,
+		IsRead:    false,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	if err := s.repo.Save(ctx, n); err != nil {
+		return nil, fmt.Errorf("failed to save notification: %w", err)
+	}
+
+	if s.topics != nil {
+		s.topics.Publish(topic, n)
+	}
+
+	return n, nil
+}
+
+// deliver fans a notification out to every channel the user's
+// preferences enable for its type, running deliveries concurrently
+// (bounded by sem) with per-channel retries and timeouts, and recording
+// one attempt per channel, with its outcome after retries, via audit if
+// one is configured.
+func (s *NotificationService) deliver(ctx context.Context, n *Notification) *MultiError {
+	channelNames := s.channelsForDelivery(ctx, n)
+	if len(channelNames) == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		merr = &MultiError{}
+	)
+
+	for _, name := range channelNames {
+		ch, ok := s.channels.Get(name)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		s.sem <- struct{}{}
+		go func(ch DeliveryChannel) {
+			defer wg.Done()
+			defer func() { <-s.sem }()
+
+			deliverCtx, cancel := context.WithTimeout(ctx, defaultChannelTimeout)
+			defer cancel()
+
+			err := s.retryPolicy.Run(deliverCtx, func() error {
+				return ch.Deliver(deliverCtx, n, DeliveryOptions{Timeout: defaultChannelTimeout})
+			})
+
+			s.recordAttempt(ctx, n.ID, ch.Name(), err)
+
+			if err != nil {
+				mu.Lock()
+				merr.Add(fmt.Errorf("%s: %w", ch.Name(), err))
+				mu.Unlock()
+			}
+		}(ch)
+	}
+
+	wg.Wait()
+	return merr
+}
+
+// channelsForDelivery resolves which channels a notification should be
+// sent through, falling back to "websocket" when no preferences
+// repository is configured or the user has no stored preferences.
+func (s *NotificationService) channelsForDelivery(ctx context.Context, n *Notification) []string {
+	if s.prefs == nil {
+		return []string{"websocket"}
+	}
+	prefs, err := s.prefs.Get(ctx, n.UserID)
+	if err != nil {
+		log.Printf("Warning: failed to load notification preferences for user %d: %v", n.UserID, err)
+		return []string{"websocket"}
+	}
+	if len(prefs.Channels) == 0 {
+		return []string{"websocket"}
+	}
+	return prefs.ChannelsFor(n.Type)
+}
+
+// recordAttempt writes a DeliveryAttempt to the audit repository, if
+// one is configured.
+func (s *NotificationService) recordAttempt(ctx context.Context, notificationID int64, channel string, deliverErr error) {
+	if s.audit == nil {
+		return
+	}
+	attempt := DeliveryAttempt{
+		NotificationID: notificationID,
+		Channel:        channel,
+		Success:        deliverErr == nil,
+		AttemptedAt:    time.Now().Format(time.RFC3339),
+	}
+	if deliverErr != nil {
+		attempt.Error = deliverErr.Error()
+	}
+	if err := s.audit.Record(ctx, attempt); err != nil {
+		log.Printf("Warning: failed to record delivery attempt for notification %d via %s: %v", notificationID, channel, err)
+	}
+}
+
 // List retrieves paginated notifications for a user
 func (s *NotificationService) List(ctx context.Context, userID int64, page, limit int) ([]Notification, int64, error) {
 	// Validate pagination parameters
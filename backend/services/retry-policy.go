@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy is an exponential backoff with jitter, used to retry a
+// single delivery channel send without letting one flaky channel retry
+// forever or in lockstep with every other attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy returns the policy NotificationService uses unless
+// overridden: 3 attempts, starting at 200ms and capped at 5s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+}
+
+// nextDelay returns the backoff delay before retry attempt `attempt`
+// (0-indexed), doubled each attempt and capped at MaxDelay, with up to
+// 50% random jitter to avoid every retry firing at once.
+func (p RetryPolicy) nextDelay(attempt int) time.Duration {
+	delay := p.BaseDelay * time.Duration(1<<uint(attempt))
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
+// Run calls fn until it succeeds, MaxAttempts is exhausted, or ctx is
+// cancelled, sleeping nextDelay between attempts. It returns the last
+// error fn returned, or ctx.Err() if ctx is cancelled while waiting to
+// retry.
+func (p RetryPolicy) Run(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < p.MaxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == p.MaxAttempts-1 {
+			break
+		}
+		select {
+		case <-time.After(p.nextDelay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
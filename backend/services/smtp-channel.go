@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+)
+
+// EmailResolver resolves the email address notifications for a user
+// should be sent to, e.g. a thin wrapper over a users table lookup.
+type EmailResolver interface {
+	EmailForUser(ctx context.Context, userID int64) (string, error)
+}
+
+// SMTPConfig holds the outgoing mail server settings smtpChannel sends
+// through.
+type SMTPConfig struct {
+	Addr string // host:port
+	Auth smtp.Auth
+	From string
+}
+
+// smtpChannel delivers notifications as plain-text email via SMTP.
+type smtpChannel struct {
+	cfg      SMTPConfig
+	resolver EmailResolver
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPChannel creates a DeliveryChannel that emails notifications to
+// the address resolver returns for the notification's UserID. Note that
+// net/smtp.SendMail doesn't accept a context, so ctx cancellation isn't
+// honored mid-send - only before the attempt starts.
+func NewSMTPChannel(cfg SMTPConfig, resolver EmailResolver) DeliveryChannel {
+	return &smtpChannel{cfg: cfg, resolver: resolver, sendMail: smtp.SendMail}
+}
+
+func (c *smtpChannel) Name() string { return "smtp" }
+
+func (c *smtpChannel) Deliver(ctx context.Context, n *Notification, opts DeliveryOptions) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	to, err := c.resolver.EmailForUser(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("smtp: resolve address: %w", err)
+	}
+	if to == "" {
+		return fmt.Errorf("smtp: no email address for user %d", n.UserID)
+	}
+
+	if err := c.sendMail(c.cfg.Addr, c.cfg.Auth, c.cfg.From, []string{to}, buildEmailMessage(c.cfg.From, to, n)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+// buildEmailMessage renders n as a minimal RFC 5322 message suitable
+// for smtp.SendMail.
+func buildEmailMessage(from, to string, n *Notification) []byte {
+	return []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", from, to, n.Title, n.Message))
+}
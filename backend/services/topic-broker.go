@@ -0,0 +1,82 @@
+package services
+
+import "sync"
+
+// FirehoseTopic is the pseudo-topic every published message is also
+// delivered to, backing the admin-only observability stream.
+const FirehoseTopic = "*"
+
+// TopicBroker fans published notifications out to live subscribers of a
+// topic (used by the SSE, long-poll, and raw stream handlers) and backs
+// them with a MessageCache so a subscriber that connects late can replay
+// everything it missed via a `since` cursor.
+type TopicBroker struct {
+	cache *MessageCache
+
+	mu   sync.Mutex
+	subs map[string]map[chan TopicMessage]struct{}
+}
+
+// NewTopicBroker creates a broker backed by cache.
+func NewTopicBroker(cache *MessageCache) *TopicBroker {
+	if cache == nil {
+		cache = NewMessageCache(0)
+	}
+	return &TopicBroker{cache: cache, subs: make(map[string]map[chan TopicMessage]struct{})}
+}
+
+// Publish stores n in the cache under topic and pushes it to every live
+// subscriber of topic as well as every FirehoseTopic subscriber. It also
+// stores a copy under FirehoseTopic, so a reconnecting firehose
+// subscriber can replay history via Since the same way any other topic
+// subscriber can, instead of only ever seeing messages published after
+// it reconnects.
+func (b *TopicBroker) Publish(topic string, n *Notification) TopicMessage {
+	msg := b.cache.Append(topic, n)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.notifyLocked(topic, msg)
+
+	if topic != FirehoseTopic {
+		fhMsg := b.cache.Append(FirehoseTopic, n)
+		b.notifyLocked(FirehoseTopic, fhMsg)
+	}
+	return msg
+}
+
+func (b *TopicBroker) notifyLocked(topic string, msg TopicMessage) {
+	for ch := range b.subs[topic] {
+		select {
+		case ch <- msg:
+		default:
+			// Slow subscriber; drop rather than block the publisher. It
+			// can catch up via Since once it's ready.
+		}
+	}
+}
+
+// Subscribe registers a channel for live messages on topic. The caller
+// must invoke the returned unsubscribe func when it stops listening.
+func (b *TopicBroker) Subscribe(topic string) (<-chan TopicMessage, func()) {
+	ch := make(chan TopicMessage, 16)
+
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[chan TopicMessage]struct{})
+	}
+	b.subs[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		delete(b.subs[topic], ch)
+		b.mu.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+// Since replays cached messages for topic newer than the given cursor.
+func (b *TopicBroker) Since(topic string, since int64) []TopicMessage {
+	return b.cache.Since(topic, since)
+}
@@ -0,0 +1,69 @@
+package services
+
+import "sync"
+
+// TopicMessage is one entry in a topic's replay cache, tagged with a
+// monotonically increasing cursor ID so late subscribers can resume via
+// `since`.
+type TopicMessage struct {
+	ID           int64         `json:"id"`
+	Topic        string        `json:"topic"`
+	Notification *Notification `json:"notification"`
+}
+
+// MessageCache retains a rolling window of recent messages per topic so
+// late subscribers can replay everything they missed, the way ntfy's
+// topic cache does.
+type MessageCache struct {
+	mu        sync.Mutex
+	retention int
+	nextID    int64
+	byTopic   map[string][]TopicMessage
+}
+
+// defaultTopicRetention is how many messages NewMessageCache keeps per
+// topic when the caller doesn't specify a retention window.
+const defaultTopicRetention = 100
+
+// NewMessageCache creates a cache that retains up to `retention`
+// messages per topic, oldest evicted first. A retention of 0 uses
+// defaultTopicRetention.
+func NewMessageCache(retention int) *MessageCache {
+	if retention <= 0 {
+		retention = defaultTopicRetention
+	}
+	return &MessageCache{retention: retention, byTopic: make(map[string][]TopicMessage)}
+}
+
+// Append stores n under topic and returns the TopicMessage it was
+// assigned, including its cursor ID.
+func (c *MessageCache) Append(topic string, n *Notification) TopicMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	msg := TopicMessage{ID: c.nextID, Topic: topic, Notification: n}
+
+	msgs := append(c.byTopic[topic], msg)
+	if len(msgs) > c.retention {
+		msgs = msgs[len(msgs)-c.retention:]
+	}
+	c.byTopic[topic] = msgs
+
+	return msg
+}
+
+// Since returns every cached message for topic with an ID greater than
+// since, oldest first.
+func (c *MessageCache) Since(topic string, since int64) []TopicMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []TopicMessage
+	for _, msg := range c.byTopic[topic] {
+		if msg.ID > since {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookTargetResolver resolves the URL notifications for a user
+// should be POSTed to, e.g. a per-user OpsGenie/PagerDuty integration
+// endpoint.
+type WebhookTargetResolver interface {
+	WebhookURLForUser(ctx context.Context, userID int64) (string, error)
+}
+
+// webhookChannel delivers notifications as a JSON POST to an
+// externally configured HTTP endpoint (OpsGenie, PagerDuty, Slack
+// incoming webhooks, ...).
+type webhookChannel struct {
+	client   *http.Client
+	resolver WebhookTargetResolver
+}
+
+// NewWebhookChannel creates a DeliveryChannel that POSTs notifications
+// as JSON to the URL resolver returns for the notification's UserID.
+// client may be nil, in which case http.DefaultClient is used.
+func NewWebhookChannel(client *http.Client, resolver WebhookTargetResolver) DeliveryChannel {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookChannel{client: client, resolver: resolver}
+}
+
+func (c *webhookChannel) Name() string { return "webhook" }
+
+func (c *webhookChannel) Deliver(ctx context.Context, n *Notification, opts DeliveryOptions) error {
+	url, err := c.resolver.WebhookURLForUser(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("webhook: resolve target: %w", err)
+	}
+	if url == "" {
+		return fmt.Errorf("webhook: no webhook URL for user %d", n.UserID)
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("webhook: marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}